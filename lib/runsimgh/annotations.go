@@ -0,0 +1,86 @@
+package runsimgh
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+)
+
+// maxAnnotationsPerUpdate is the number of annotations GitHub accepts on a single
+// Checks.UpdateCheckRun call; anything queued beyond that must go out as further PATCHes.
+const maxAnnotationsPerUpdate = 50
+
+// SimAnnotation is a single diagnostic produced by a simulation run. Flushed annotations
+// point a reviewer at the exact source line instead of being folded into the check run summary.
+type SimAnnotation struct {
+	Path            string
+	StartLine       int
+	EndLine         int
+	AnnotationLevel string
+	Title           string
+	Message         string
+}
+
+// AddAnnotations queues annotations to be attached to the active check run on the next
+// call to FlushAnnotations. It does not talk to the GitHub API.
+func (gh *Integration) AddAnnotations(annotations []SimAnnotation) {
+	gh.pendingAnnotations = append(gh.pendingAnnotations, annotations...)
+}
+
+// FlushAnnotations posts all queued annotations to the active check run, batching them into
+// groups of maxAnnotationsPerUpdate since GitHub rejects a larger batch in a single PATCH.
+// Summary and Title are reposted with every batch so earlier text isn't clobbered. This is the
+// one method in the package that can block for a while waiting out a rate limit, so it takes
+// ctx and honors cancellation during that wait instead of just during the HTTP calls.
+func (gh *Integration) FlushAnnotations(ctx context.Context, summary, title *string) (err error) {
+	for len(gh.pendingAnnotations) > 0 {
+		batch := gh.pendingAnnotations
+		if len(batch) > maxAnnotationsPerUpdate {
+			batch = batch[:maxAnnotationsPerUpdate]
+		}
+
+		opt := github.UpdateCheckRunOptions{
+			Name: gh.ActiveCheckRun.GetName(),
+			Output: &github.CheckRunOutput{
+				Title:       title,
+				Summary:     summary,
+				Annotations: toGithubAnnotations(batch),
+			},
+		}
+
+		var resp *github.Response
+		gh.ActiveCheckRun, resp, err = gh.Client.Checks.UpdateCheckRun(ctx, gh.GetOwner(), gh.GetRepo(),
+			gh.ActiveCheckRun.GetID(), opt)
+		if err != nil {
+			return
+		}
+
+		gh.pendingAnnotations = gh.pendingAnnotations[len(batch):]
+
+		if resp != nil && resp.Rate.Remaining == 0 && len(gh.pendingAnnotations) > 0 {
+			select {
+			case <-time.After(time.Until(resp.Rate.Reset.Time)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	return
+}
+
+func toGithubAnnotations(annotations []SimAnnotation) []*github.CheckRunAnnotation {
+	out := make([]*github.CheckRunAnnotation, len(annotations))
+	for i, a := range annotations {
+		out[i] = &github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(a.StartLine),
+			EndLine:         github.Int(a.EndLine),
+			AnnotationLevel: github.String(a.AnnotationLevel),
+			Title:           github.String(a.Title),
+			Message:         github.String(a.Message),
+		}
+	}
+	return out
+}
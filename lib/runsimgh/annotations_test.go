@@ -0,0 +1,117 @@
+package runsimgh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v27/github"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport answers every request with a canned CheckRun and records how many
+// annotations each request body carried, so tests can assert on FlushAnnotations' batching
+// without hitting the network.
+type recordingTransport struct {
+	annotationCounts []int
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded struct {
+		Output struct {
+			Annotations []json.RawMessage `json:"annotations"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, err
+	}
+	t.annotationCounts = append(t.annotationCounts, len(decoded.Output.Annotations))
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"id":1,"name":"simulate"}`))),
+		Header:     make(http.Header),
+	}, nil
+}
+
+func newAnnotations(n int) []SimAnnotation {
+	out := make([]SimAnnotation, n)
+	for i := range out {
+		out[i] = SimAnnotation{Path: "main.go", StartLine: i + 1, EndLine: i + 1, AnnotationLevel: "warning", Title: "t", Message: "m"}
+	}
+	return out
+}
+
+// TestFlushAnnotationsBatchesAtLimit proves FlushAnnotations splits exactly at
+// maxAnnotationsPerUpdate: 50 queued annotations go out in one PATCH, 51 go out in two.
+func TestFlushAnnotationsBatchesAtLimit(t *testing.T) {
+	owner, repo, name := "octocat", "hello-world", "simulate"
+	summary, title := github.String("summary"), github.String("title")
+
+	atLimit := &recordingTransport{}
+	gh := &Integration{
+		Client:         github.NewClient(&http.Client{Transport: atLimit}),
+		RepoOwner:      &owner,
+		RepoName:       &repo,
+		ActiveCheckRun: &github.CheckRun{ID: github.Int64(1), Name: &name},
+	}
+	gh.AddAnnotations(newAnnotations(maxAnnotationsPerUpdate))
+	require.NoError(t, gh.FlushAnnotations(context.Background(), summary, title))
+	require.Equal(t, []int{maxAnnotationsPerUpdate}, atLimit.annotationCounts)
+
+	overLimit := &recordingTransport{}
+	gh = &Integration{
+		Client:         github.NewClient(&http.Client{Transport: overLimit}),
+		RepoOwner:      &owner,
+		RepoName:       &repo,
+		ActiveCheckRun: &github.CheckRun{ID: github.Int64(1), Name: &name},
+	}
+	gh.AddAnnotations(newAnnotations(maxAnnotationsPerUpdate + 1))
+	require.NoError(t, gh.FlushAnnotations(context.Background(), summary, title))
+	require.Equal(t, []int{maxAnnotationsPerUpdate, 1}, overLimit.annotationCounts)
+}
+
+// TestFlushAnnotationsCancelsDuringRateLimitWait proves a caller can cancel ctx to stop an
+// in-progress rate-limit wait instead of blocking until resp.Rate.Reset.Time, which chunk0-6
+// threaded ctx through every other blocking Integration method to make possible.
+func TestFlushAnnotationsCancelsDuringRateLimitWait(t *testing.T) {
+	owner, repo, name := "octocat", "hello-world", "simulate"
+	summary, title := github.String("summary"), github.String("title")
+
+	gh := &Integration{
+		Client:         github.NewClient(&http.Client{Transport: &rateLimitedTransport{}}),
+		RepoOwner:      &owner,
+		RepoName:       &repo,
+		ActiveCheckRun: &github.CheckRun{ID: github.Int64(1), Name: &name},
+	}
+	gh.AddAnnotations(newAnnotations(maxAnnotationsPerUpdate + 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := gh.FlushAnnotations(ctx, summary, title)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// rateLimitedTransport answers every request as if the rate limit is already exhausted and
+// won't reset until far in the future, forcing FlushAnnotations into its wait-for-reset path.
+type rateLimitedTransport struct{}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := make(http.Header)
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "4102444800") // 2100-01-01, far enough out to never fire
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"id":1,"name":"simulate"}`))),
+		Header:     header,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package runsimgh
+
+import (
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// stateKeyer is implemented by anything PutState-d into a BoltStateStore so the store knows
+// what key to file it under; Integration implements it via StateKey.
+type stateKeyer interface {
+	StateKey() string
+}
+
+// BoltStateStore is a StateStore backed by a local BoltDB file. It lets the simulator run
+// locally or in environments without AWS. Each store reads and writes the single bucket named
+// when it was opened, with rows keyed like "owner/repo::prNum" and JSON-encoded values; no
+// other buckets (such as "pulls" or "globalLocks") are created or used.
+type BoltStateStore struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// NewBoltStateStore opens (creating if necessary) a BoltDB file at path and returns a
+// StateStore scoped to the given bucket.
+func NewBoltStateStore(path, bucket string) (*BoltStateStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStateStore{db: db, bucket: []byte(bucket)}, nil
+}
+
+func (s *BoltStateStore) GetState(key string, out interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(s.bucket).Get([]byte(key))
+		if value == nil {
+			return errors.New("ErrorStateNotFound: " + key)
+		}
+		return json.Unmarshal(value, out)
+	})
+}
+
+func (s *BoltStateStore) PutState(in interface{}) error {
+	keyer, ok := in.(stateKeyer)
+	if !ok {
+		return errors.New("ErrorStateNotKeyed")
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte(keyer.StateKey()), data)
+	})
+}
+
+func (s *BoltStateStore) DeleteState(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete([]byte(key))
+	})
+}
+
+// Close releases the underlying BoltDB file handle.
+func (s *BoltStateStore) Close() error {
+	return s.db.Close()
+}
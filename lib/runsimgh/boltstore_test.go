@@ -0,0 +1,57 @@
+package runsimgh
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBoltStateStoreRoundTrip exercises PutState/GetState/DeleteState the way ConfigFromState
+// does: addressing the row by stateKeyFor(owner, repo, prNum) rather than by StateKey's return
+// value directly, proving the two agree on the same key.
+func TestBoltStateStoreRoundTrip(t *testing.T) {
+	store, err := NewBoltStateStore(filepath.Join(t.TempDir(), "state.db"), "checkRuns")
+	require.NoError(t, err)
+	defer store.Close()
+
+	owner, repo := "octocat", "hello-world"
+	in := &Integration{RepoOwner: &owner, RepoName: &repo, PrNum: 7}
+	require.NoError(t, store.PutState(in))
+
+	key := stateKeyFor(owner, repo, 7)
+
+	var out Integration
+	require.NoError(t, store.GetState(key, &out))
+	require.Equal(t, owner, out.GetOwner())
+	require.Equal(t, repo, out.GetRepo())
+	require.EqualValues(t, 7, out.GetPrNum())
+
+	require.NoError(t, store.DeleteState(key))
+
+	err = store.GetState(key, &out)
+	require.Error(t, err)
+}
+
+// TestBoltStateStoreRoundTripDistinctEntities proves two different owner/repo/PR rows don't
+// clobber each other the way a single fixed row key would.
+func TestBoltStateStoreRoundTripDistinctEntities(t *testing.T) {
+	store, err := NewBoltStateStore(filepath.Join(t.TempDir(), "state.db"), "checkRuns")
+	require.NoError(t, err)
+	defer store.Close()
+
+	ownerA, repoA := "octocat", "hello-world"
+	ownerB, repoB := "octodog", "goodbye-world"
+	inA := &Integration{RepoOwner: &ownerA, RepoName: &repoA, PrNum: 1}
+	inB := &Integration{RepoOwner: &ownerB, RepoName: &repoB, PrNum: 2}
+
+	require.NoError(t, store.PutState(inA))
+	require.NoError(t, store.PutState(inB))
+
+	var outA, outB Integration
+	require.NoError(t, store.GetState(stateKeyFor(ownerA, repoA, 1), &outA))
+	require.NoError(t, store.GetState(stateKeyFor(ownerB, repoB, 2), &outB))
+
+	require.Equal(t, ownerA, outA.GetOwner())
+	require.Equal(t, ownerB, outB.GetOwner())
+}
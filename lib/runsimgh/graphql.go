@@ -0,0 +1,383 @@
+package runsimgh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v27/github"
+)
+
+const graphQLEndpoint = "https://api.github.com/graphql"
+
+// maxRefsPerQuery bounds how many aliased repository lookups go in a single GraphQL request.
+const maxRefsPerQuery = 50
+
+// checkSuitesPageSize and checkRunsPageSize bound each page of suites/runs fetched per request;
+// ListActiveCheckRuns follows hasNextPage/endCursor rather than assuming one page is enough.
+const checkSuitesPageSize = 20
+const checkRunsPageSize = 20
+
+// RepoRef identifies a single commit ListActiveCheckRuns should resolve an active check run for.
+type RepoRef struct {
+	Owner string
+	Repo  string
+	SHA   string
+}
+
+// RateLimit is the GraphQL rate-limit status GitHub reported for the last aliased first-page
+// request a ListActiveCheckRuns call made. Cost is that one request's cost; the costs of
+// earlier batches and of the per-ref cursor follow-up requests are not added in.
+type RateLimit struct {
+	Cost      int
+	Remaining int
+	ResetAt   string
+}
+
+type checkRunNode struct {
+	DatabaseID int64  `json:"databaseId"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type checkRunConnection struct {
+	PageInfo pageInfo       `json:"pageInfo"`
+	Nodes    []checkRunNode `json:"nodes"`
+}
+
+type checkSuiteNode struct {
+	ID        string             `json:"id"`
+	CheckRuns checkRunConnection `json:"checkRuns"`
+}
+
+type checkSuiteConnection struct {
+	PageInfo pageInfo         `json:"pageInfo"`
+	Nodes    []checkSuiteNode `json:"nodes"`
+}
+
+// ListActiveCheckRuns resolves the active (non-concluded) check run named checkName for every
+// ref in as few GraphQL requests as possible, instead of one REST ListCheckRunsForRef call per
+// ref as SetActiveCheckRun does. Refs with no matching check run are simply absent from the
+// result map. This is the batched counterpart meant for a poller watching many open PRs at
+// once; single-PR callers should keep using SetActiveCheckRun.
+//
+// The first page of checkSuites/checkRuns for every ref is fetched in one aliased request per
+// maxRefsPerQuery refs. Any ref whose match isn't on that first page is followed up
+// individually via cursor, paginating checkSuites and then each suite's checkRuns until the
+// active run turns up or both connections are exhausted - so a ref with many suites or runs
+// can't silently lose a match past the first page.
+//
+// This hand-builds GraphQL requests over plain HTTP rather than using githubv4: githubv4
+// queries are static Go structs, and the first-page request here aliases a number of
+// repository lookups only known at call time (one per ref), which a struct-tag query can't
+// express.
+func (gh *Integration) ListActiveCheckRuns(ctx context.Context, refs []RepoRef, checkName string) (map[RepoRef]*github.CheckRun, RateLimit, error) {
+	results := make(map[RepoRef]*github.CheckRun, len(refs))
+	var rate RateLimit
+	var needsMore []RepoRef
+
+	for start := 0; start < len(refs); start += maxRefsPerQuery {
+		end := start + maxRefsPerQuery
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		pending, batchRate, err := gh.queryActiveCheckRunsFirstPage(ctx, refs[start:end], checkName, results)
+		if err != nil {
+			return nil, rate, err
+		}
+		needsMore = append(needsMore, pending...)
+		rate = batchRate
+	}
+
+	for _, ref := range needsMore {
+		if err := gh.paginateActiveCheckRun(ctx, ref, checkName, results); err != nil {
+			return nil, rate, err
+		}
+	}
+
+	return results, rate, nil
+}
+
+// queryActiveCheckRunsFirstPage issues one GraphQL request covering batch, aliasing a
+// repository/object lookup per ref since GraphQL has no native "for each ref" batching
+// primitive. It returns the refs whose first page didn't contain an active run but has more
+// pages to check, for the caller to follow up on individually.
+func (gh *Integration) queryActiveCheckRunsFirstPage(ctx context.Context, batch []RepoRef, checkName string, results map[RepoRef]*github.CheckRun) ([]RepoRef, RateLimit, error) {
+	var query strings.Builder
+	query.WriteString("query(")
+	for i := range batch {
+		fmt.Fprintf(&query, "$owner%d: String!, $name%d: String!, $expr%d: String!, ", i, i, i)
+	}
+	query.WriteString("$checkName: String) {\n  rateLimit { cost remaining resetAt }\n")
+	for i := range batch {
+		fmt.Fprintf(&query, `  r%d: repository(owner: $owner%d, name: $name%d) {
+    object(expression: $expr%d) {
+      ... on Commit {
+        checkSuites(first: %d) {
+          pageInfo { hasNextPage endCursor }
+          nodes {
+            id
+            checkRuns(first: %d, filterBy: {checkName: $checkName}) {
+              pageInfo { hasNextPage endCursor }
+              nodes { databaseId name status conclusion }
+            }
+          }
+        }
+      }
+    }
+  }
+`, i, i, i, i, checkSuitesPageSize, checkRunsPageSize)
+	}
+	query.WriteString("}")
+
+	variables := map[string]interface{}{"checkName": checkName}
+	for i, ref := range batch {
+		variables[fmt.Sprintf("owner%d", i)] = ref.Owner
+		variables[fmt.Sprintf("name%d", i)] = ref.Repo
+		variables[fmt.Sprintf("expr%d", i)] = ref.SHA
+	}
+
+	data, rate, err := gh.postGraphQL(ctx, query.String(), variables)
+	if err != nil {
+		return nil, RateLimit{}, err
+	}
+
+	var needsMore []RepoRef
+	for i, ref := range batch {
+		raw, ok := data[fmt.Sprintf("r%d", i)]
+		if !ok {
+			continue
+		}
+
+		var repo struct {
+			Object struct {
+				CheckSuites checkSuiteConnection `json:"checkSuites"`
+			} `json:"object"`
+		}
+		if err := json.Unmarshal(raw, &repo); err != nil {
+			return nil, RateLimit{}, err
+		}
+
+		if applyCheckSuites(ref, repo.Object.CheckSuites.Nodes, results) {
+			continue
+		}
+
+		if repo.Object.CheckSuites.PageInfo.HasNextPage || anySuiteHasMoreRuns(repo.Object.CheckSuites.Nodes) {
+			needsMore = append(needsMore, ref)
+		}
+	}
+
+	return needsMore, rate, nil
+}
+
+// paginateActiveCheckRun follows up on a single ref whose first page (from
+// queryActiveCheckRunsFirstPage) didn't contain an active run, paginating its checkSuites via
+// cursor and, for any suite whose checkRuns weren't fully fetched, that suite's checkRuns too.
+func (gh *Integration) paginateActiveCheckRun(ctx context.Context, ref RepoRef, checkName string, results map[RepoRef]*github.CheckRun) error {
+	var cursor interface{}
+
+	for {
+		data, _, err := gh.postGraphQL(ctx, singleRefCheckSuitesQuery(), map[string]interface{}{
+			"owner": ref.Owner, "name": ref.Repo, "expr": ref.SHA,
+			"checkName": checkName, "cursor": cursor,
+		})
+		if err != nil {
+			return err
+		}
+
+		raw, ok := data["repository"]
+		if !ok {
+			return nil
+		}
+		var repo struct {
+			Object struct {
+				CheckSuites checkSuiteConnection `json:"checkSuites"`
+			} `json:"object"`
+		}
+		if err := json.Unmarshal(raw, &repo); err != nil {
+			return err
+		}
+
+		for _, suite := range repo.Object.CheckSuites.Nodes {
+			found, err := gh.findActiveRunInSuite(ctx, ref, suite, checkName, results)
+			if err != nil {
+				return err
+			}
+			if found {
+				return nil
+			}
+		}
+
+		if !repo.Object.CheckSuites.PageInfo.HasNextPage {
+			return nil
+		}
+		cursor = repo.Object.CheckSuites.PageInfo.EndCursor
+	}
+}
+
+// findActiveRunInSuite looks for an active run already fetched on suite, paginating that
+// suite's own checkRuns via its GraphQL node ID if the first page wasn't enough.
+func (gh *Integration) findActiveRunInSuite(ctx context.Context, ref RepoRef, suite checkSuiteNode, checkName string, results map[RepoRef]*github.CheckRun) (bool, error) {
+	runs := suite.CheckRuns.Nodes
+	info := suite.CheckRuns.PageInfo
+
+	for {
+		if run := firstActiveRun(runs); run != nil {
+			results[ref] = toGithubCheckRun(run)
+			return true, nil
+		}
+		if !info.HasNextPage {
+			return false, nil
+		}
+
+		data, _, err := gh.postGraphQL(ctx, suiteCheckRunsQuery(), map[string]interface{}{
+			"id": suite.ID, "checkName": checkName, "cursor": info.EndCursor,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		raw, ok := data["node"]
+		if !ok {
+			return false, nil
+		}
+		var node struct {
+			CheckRuns checkRunConnection `json:"checkRuns"`
+		}
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return false, err
+		}
+		runs = node.CheckRuns.Nodes
+		info = node.CheckRuns.PageInfo
+	}
+}
+
+func applyCheckSuites(ref RepoRef, suites []checkSuiteNode, results map[RepoRef]*github.CheckRun) bool {
+	for _, suite := range suites {
+		if run := firstActiveRun(suite.CheckRuns.Nodes); run != nil {
+			results[ref] = toGithubCheckRun(run)
+			return true
+		}
+	}
+	return false
+}
+
+func anySuiteHasMoreRuns(suites []checkSuiteNode) bool {
+	for _, suite := range suites {
+		if suite.CheckRuns.PageInfo.HasNextPage {
+			return true
+		}
+	}
+	return false
+}
+
+func firstActiveRun(runs []checkRunNode) *checkRunNode {
+	for i := range runs {
+		if runs[i].Conclusion == "" {
+			return &runs[i]
+		}
+	}
+	return nil
+}
+
+func toGithubCheckRun(run *checkRunNode) *github.CheckRun {
+	return &github.CheckRun{
+		ID:         github.Int64(run.DatabaseID),
+		Name:       github.String(run.Name),
+		Status:     github.String(run.Status),
+		Conclusion: github.String(run.Conclusion),
+	}
+}
+
+func singleRefCheckSuitesQuery() string {
+	return fmt.Sprintf(`query($owner: String!, $name: String!, $expr: String!, $checkName: String, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    object(expression: $expr) {
+      ... on Commit {
+        checkSuites(first: %d, after: $cursor) {
+          pageInfo { hasNextPage endCursor }
+          nodes {
+            id
+            checkRuns(first: %d, filterBy: {checkName: $checkName}) {
+              pageInfo { hasNextPage endCursor }
+              nodes { databaseId name status conclusion }
+            }
+          }
+        }
+      }
+    }
+  }
+}`, checkSuitesPageSize, checkRunsPageSize)
+}
+
+func suiteCheckRunsQuery() string {
+	return fmt.Sprintf(`query($id: ID!, $checkName: String, $cursor: String) {
+  node(id: $id) {
+    ... on CheckSuite {
+      checkRuns(first: %d, after: $cursor, filterBy: {checkName: $checkName}) {
+        pageInfo { hasNextPage endCursor }
+        nodes { databaseId name status conclusion }
+      }
+    }
+  }
+}`, checkRunsPageSize)
+}
+
+// postGraphQL executes query/variables against the GitHub GraphQL API using the Integration's
+// REST client's underlying http.Client, and returns the top-level "data" fields undecoded
+// (each caller knows its own response shape) along with the request's reported RateLimit.
+func (gh *Integration) postGraphQL(ctx context.Context, query string, variables map[string]interface{}) (map[string]json.RawMessage, RateLimit, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, RateLimit{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphQLEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, RateLimit{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := gh.Client.Client().Do(req)
+	if err != nil {
+		return nil, RateLimit{}, err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, RateLimit{}, err
+	}
+	if len(envelope.Errors) > 0 {
+		return nil, RateLimit{}, fmt.Errorf("graphql: %s", envelope.Errors[0].Message)
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(envelope.Data, &data); err != nil {
+		return nil, RateLimit{}, err
+	}
+
+	var rate RateLimit
+	if raw, ok := data["rateLimit"]; ok {
+		if err := json.Unmarshal(raw, &rate); err != nil {
+			return nil, RateLimit{}, err
+		}
+	}
+
+	return data, rate, nil
+}
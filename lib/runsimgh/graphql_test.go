@@ -0,0 +1,65 @@
+package runsimgh
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v27/github"
+	"github.com/stretchr/testify/require"
+)
+
+// sequencedTransport serves canned GraphQL responses in order, one per RoundTrip call,
+// repeating the last response if more calls come in than were primed.
+type sequencedTransport struct {
+	responses []string
+	calls     int
+}
+
+func (t *sequencedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idx := t.calls
+	if idx >= len(t.responses) {
+		idx = len(t.responses) - 1
+	}
+	t.calls++
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(t.responses[idx])),
+		Header:     make(http.Header),
+	}, nil
+}
+
+func TestListActiveCheckRunsFollowsCursorPastFirstPage(t *testing.T) {
+	firstPage := `{"data":{
+		"rateLimit": {"cost": 1, "remaining": 4999, "resetAt": "2026-01-01T00:00:00Z"},
+		"r0": {"object": {"checkSuites": {
+			"pageInfo": {"hasNextPage": true, "endCursor": "cursor-1"},
+			"nodes": [{"id": "suite-1", "checkRuns": {
+				"pageInfo": {"hasNextPage": false, "endCursor": ""},
+				"nodes": [{"databaseId": 1, "name": "simulate", "status": "completed", "conclusion": "success"}]
+			}}]
+		}}}
+	}}`
+	secondPage := `{"data": {"repository": {"object": {"checkSuites": {
+		"pageInfo": {"hasNextPage": false, "endCursor": ""},
+		"nodes": [{"id": "suite-2", "checkRuns": {
+			"pageInfo": {"hasNextPage": false, "endCursor": ""},
+			"nodes": [{"databaseId": 2, "name": "simulate", "status": "in_progress", "conclusion": ""}]
+		}}]
+	}}}}}`
+
+	client := github.NewClient(&http.Client{
+		Transport: &sequencedTransport{responses: []string{firstPage, secondPage}},
+	})
+	gh := &Integration{Client: client}
+
+	ref := RepoRef{Owner: "octocat", Repo: "hello-world", SHA: "deadbeef"}
+	results, _, err := gh.ListActiveCheckRuns(context.Background(), []RepoRef{ref}, "simulate")
+	require.NoError(t, err)
+
+	require.Contains(t, results, ref)
+	require.EqualValues(t, 2, results[ref].GetID())
+}
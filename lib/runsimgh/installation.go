@@ -0,0 +1,162 @@
+package runsimgh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	ghapp "github.com/bradleyfalzon/ghinstallation"
+	"github.com/google/go-github/v27/github"
+)
+
+var errNoInstallationManager = errors.New("ErrorNoInstallationManager")
+
+// InstallationManager mints and caches per-installation *github.Client for a single GitHub
+// App, so one runner can service every org the App is installed into instead of assuming a
+// single InstallationID baked into its state. Each installation's ghinstallation.Transport
+// is cached and reused, and refreshes its token on expiry the same way a single-installation
+// Integration already does.
+type InstallationManager struct {
+	integrationID int64
+	privateKey    []byte
+	base          http.RoundTripper
+	appClient     *github.Client
+
+	mu         sync.Mutex
+	transports map[int64]*ghapp.Transport
+}
+
+// NewInstallationManager builds an InstallationManager for the App identified by
+// integrationID, authenticating individual installations with privateKey.
+func NewInstallationManager(integrationID int64, privateKey []byte, base http.RoundTripper) (*InstallationManager, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	appTransport, err := ghapp.NewAppsTransport(base, integrationID, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstallationManager{
+		integrationID: integrationID,
+		privateKey:    privateKey,
+		base:          base,
+		appClient:     github.NewClient(&http.Client{Transport: appTransport}),
+		transports:    make(map[int64]*ghapp.Transport),
+	}, nil
+}
+
+// ClientForRepo returns a *github.Client authenticated as the installation covering
+// owner/repo, looking up the installation ID via Apps.FindRepositoryInstallation.
+func (m *InstallationManager) ClientForRepo(ctx context.Context, owner, repo string) (*github.Client, error) {
+	installation, _, err := m.appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("finding installation for %s/%s: %w", owner, repo, err)
+	}
+
+	return m.ClientForInstallation(installation.GetID())
+}
+
+// ClientForInstallation returns a *github.Client authenticated as the given installation,
+// reusing a cached transport so repeated calls don't re-authenticate the installation. The
+// transport refreshes its token on its own nearing expiry, and the returned client also
+// retries once on a 401 by invalidating and rebuilding the cached transport first - covering
+// a token revoked out-of-band or an installation that was suspended and reinstalled, which
+// ghinstallation.Transport's own expiry-based refresh wouldn't catch.
+func (m *InstallationManager) ClientForInstallation(installationID int64) (*github.Client, error) {
+	if _, err := m.transportFor(installationID); err != nil {
+		return nil, err
+	}
+
+	return github.NewClient(&http.Client{
+		Transport: &installationTransport{manager: m, installationID: installationID},
+	}), nil
+}
+
+// InvalidateInstallation evicts the cached transport for installationID so the next
+// transportFor call re-authenticates from scratch. installationTransport calls this
+// automatically on a 401; exposed so a caller that observes a 401 some other way (e.g. via a
+// REST call made on a *github.Client handed out earlier) can force the same recovery.
+func (m *InstallationManager) InvalidateInstallation(installationID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.transports, installationID)
+}
+
+// transportFor returns the cached ghinstallation.Transport for installationID, authenticating
+// and caching one if this is the first request for it.
+func (m *InstallationManager) transportFor(installationID int64) (*ghapp.Transport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if transport, ok := m.transports[installationID]; ok {
+		return transport, nil
+	}
+
+	transport, err := ghapp.New(m.base, m.integrationID, installationID, m.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	m.transports[installationID] = transport
+	return transport, nil
+}
+
+// installationTransport wraps a cached per-installation transport, retrying a request exactly
+// once on a 401 after invalidating and rebuilding that installation's transport.
+type installationTransport struct {
+	manager        *InstallationManager
+	installationID int64
+}
+
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.manager.transportFor(t.installationID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// There's a body but no way to replay it, so retrying would send an empty body. Most
+		// calls this package makes are bodyless GETs, where req.Body is nil and GetBody is
+		// never populated by net/http in the first place - those always retry.
+		return resp, nil
+	}
+
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		if body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+
+	t.manager.InvalidateInstallation(t.installationID)
+	retryTransport, err := t.manager.transportFor(t.installationID)
+	if err != nil {
+		return nil, err
+	}
+
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	return retryTransport.RoundTrip(retryReq)
+}
+
+// ConnectInstallation resolves gh.Client from the Integration's InstallationManager (set via
+// WithInstallationManager) for gh.RepoOwner/gh.RepoName. It's the fleet analogue of the
+// single-installation transport ConfigFromScratch/ConfigFromState set up from a known
+// InstallationID.
+func (gh *Integration) ConnectInstallation() (err error) {
+	if gh.installations == nil {
+		return errNoInstallationManager
+	}
+
+	gh.Client, err = gh.installations.ClientForRepo(gh.context(), gh.GetOwner(), gh.GetRepo())
+	return
+}
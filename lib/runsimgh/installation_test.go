@@ -0,0 +1,101 @@
+package runsimgh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAndFlakyTransport fakes both halves of a real installation flow: it answers
+// ghinstallation's own access-token exchange with a canned token, and fails the *first*
+// non-token request with a 401 before succeeding on every request after, so tests can exercise
+// installationTransport's invalidate-and-retry path end to end.
+type tokenAndFlakyTransport struct {
+	calls  int
+	bodies []string
+}
+
+func (t *tokenAndFlakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "access_tokens") {
+		return &http.Response{
+			StatusCode: http.StatusCreated,
+			Body:       io.NopCloser(strings.NewReader(`{"token":"t","expires_at":"2099-01-01T00:00:00Z"}`)),
+			Header:     make(http.Header),
+		}, nil
+	}
+
+	t.calls++
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		t.bodies = append(t.bodies, string(body))
+	}
+
+	status := http.StatusOK
+	if t.calls == 1 {
+		status = http.StatusUnauthorized
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+func testInstallationManager(t *testing.T, base http.RoundTripper) *InstallationManager {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	manager, err := NewInstallationManager(1, pemKey, base)
+	require.NoError(t, err)
+	return manager
+}
+
+// TestInstallationTransportRetriesBodylessGetOn401 proves a bodyless GET - the shape of nearly
+// every request this package makes (ListCheckRunsForRef, GetCheckRun, PullRequests.Get,
+// FindRepositoryInstallation) - is retried after a 401 even though req.GetBody is nil, since a
+// nil Body needs no replay in the first place.
+func TestInstallationTransportRetriesBodylessGetOn401(t *testing.T) {
+	fake := &tokenAndFlakyTransport{}
+	manager := testInstallationManager(t, fake)
+
+	client, err := manager.ClientForInstallation(42)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/o/r/pulls/1", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, fake.calls)
+}
+
+// TestInstallationTransportReplaysBodyOnRetry proves a request with a replayable body (the
+// CreateCheckRun/UpdateCheckRun case) is retried with the same body rather than an empty one.
+func TestInstallationTransportReplaysBodyOnRetry(t *testing.T) {
+	fake := &tokenAndFlakyTransport{}
+	manager := testInstallationManager(t, fake)
+
+	client, err := manager.ClientForInstallation(42)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, "https://api.github.com/repos/o/r/check-runs/1",
+		bytes.NewReader([]byte(`{"status":"completed"}`)))
+	require.NoError(t, err)
+	require.NotNil(t, req.GetBody)
+
+	resp, err := client.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{`{"status":"completed"}`, `{"status":"completed"}`}, fake.bodies)
+}
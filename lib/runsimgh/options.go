@@ -0,0 +1,157 @@
+package runsimgh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// SecretProvider resolves secrets (namely the GitHub App's private key) needed to
+// authenticate an Integration's client. runsimaws.Ssm satisfies this interface as-is.
+type SecretProvider interface {
+	GetParameter(id string) (string, error)
+}
+
+// Option configures an Integration built by NewIntegration.
+type Option func(*Integration) error
+
+// WithRepo sets the owner and name of the repository the Integration operates on.
+func WithRepo(owner, name string) Option {
+	return func(gh *Integration) error {
+		if owner == "" || name == "" {
+			return errors.New("ErrorInvalidOption: WithRepo requires a non-empty owner and name")
+		}
+		gh.RepoOwner = &owner
+		gh.RepoName = &name
+		return nil
+	}
+}
+
+// WithPullRequest sets the pull request number the Integration tracks.
+func WithPullRequest(num int) Option {
+	return func(gh *Integration) error {
+		if num <= 0 {
+			return errors.New("ErrorInvalidOption: WithPullRequest requires a positive PR number")
+		}
+		gh.PrNum = int64(num)
+		return nil
+	}
+}
+
+// WithApp sets the GitHub App's integration ID and installation ID.
+func WithApp(integrationID, installationID int64) Option {
+	return func(gh *Integration) error {
+		if integrationID <= 0 || installationID <= 0 {
+			return errors.New("ErrorInvalidOption: WithApp requires a positive integration and installation ID")
+		}
+		gh.IntegrationID = integrationID
+		gh.InstallationID = installationID
+		return nil
+	}
+}
+
+// WithCheckRunName sets the name of the check run the Integration creates and updates.
+func WithCheckRunName(name string) Option {
+	return func(gh *Integration) error {
+		if name == "" {
+			return errors.New("ErrorInvalidOption: WithCheckRunName requires a non-empty name")
+		}
+		gh.CheckRunName = &name
+		return nil
+	}
+}
+
+// WithStateStore sets the StateStore the Integration persists itself to.
+func WithStateStore(store StateStore) Option {
+	return func(gh *Integration) error {
+		if store == nil {
+			return errors.New("ErrorInvalidOption: WithStateStore requires a non-nil StateStore")
+		}
+		gh.State = store
+		return nil
+	}
+}
+
+// WithSecretProvider sets the SecretProvider used to resolve the GitHub App's private key.
+func WithSecretProvider(provider SecretProvider) Option {
+	return func(gh *Integration) error {
+		if provider == nil {
+			return errors.New("ErrorInvalidOption: WithSecretProvider requires a non-nil SecretProvider")
+		}
+		gh.secrets = provider
+		return nil
+	}
+}
+
+// WithHTTPClient overrides the base http.Client the GitHub App transport is layered on top of.
+func WithHTTPClient(client *http.Client) Option {
+	return func(gh *Integration) error {
+		if client == nil {
+			return errors.New("ErrorInvalidOption: WithHTTPClient requires a non-nil http.Client")
+		}
+		gh.httpClient = client
+		return nil
+	}
+}
+
+// WithInstallationManager configures the Integration to resolve its GitHub client lazily via
+// ConnectInstallation, from the App-wide InstallationManager, instead of from a single known
+// InstallationID set with WithApp.
+func WithInstallationManager(mgr *InstallationManager) Option {
+	return func(gh *Integration) error {
+		if mgr == nil {
+			return errors.New("ErrorInvalidOption: WithInstallationManager requires a non-nil InstallationManager")
+		}
+		gh.installations = mgr
+		return nil
+	}
+}
+
+// WithContext sets the context used for API calls that don't take one explicitly.
+func WithContext(ctx context.Context) Option {
+	return func(gh *Integration) error {
+		if ctx == nil {
+			return errors.New("ErrorInvalidOption: WithContext requires a non-nil context")
+		}
+		gh.ctx = ctx
+		return nil
+	}
+}
+
+// NewIntegration builds an Integration from typed options, replacing the positional-string
+// ConfigFromScratch footgun with validated, named construction.
+func NewIntegration(opts ...Option) (*Integration, error) {
+	gh := &Integration{
+		IntegrationType: stringPtr("GitHub"),
+	}
+
+	for _, opt := range opts {
+		if err := opt(gh); err != nil {
+			return nil, err
+		}
+	}
+
+	return gh, nil
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// context returns the context an Integration was built with, defaulting to Background for
+// integrations constructed without WithContext (e.g. via ConfigFromState/ConfigFromScratch).
+func (gh *Integration) context() context.Context {
+	if gh.ctx != nil {
+		return gh.ctx
+	}
+	return context.Background()
+}
+
+// transport returns the base RoundTripper the GitHub App transport is layered on top of,
+// defaulting to http.DefaultTransport for integrations constructed without WithHTTPClient.
+func (gh *Integration) transport() http.RoundTripper {
+	if gh.httpClient != nil && gh.httpClient.Transport != nil {
+		return gh.httpClient.Transport
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,85 @@
+package runsimgh
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOptionsValidation table-tests each With* validator's rejection of its zero/invalid value.
+func TestOptionsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Option
+		wantErr string
+	}{
+		{"WithRepo empty owner", WithRepo("", "repo"), "WithRepo"},
+		{"WithRepo empty name", WithRepo("owner", ""), "WithRepo"},
+		{"WithPullRequest zero", WithPullRequest(0), "WithPullRequest"},
+		{"WithPullRequest negative", WithPullRequest(-1), "WithPullRequest"},
+		{"WithApp zero integrationID", WithApp(0, 1), "WithApp"},
+		{"WithApp zero installationID", WithApp(1, 0), "WithApp"},
+		{"WithCheckRunName empty", WithCheckRunName(""), "WithCheckRunName"},
+		{"WithStateStore nil", WithStateStore(nil), "WithStateStore"},
+		{"WithSecretProvider nil", WithSecretProvider(nil), "WithSecretProvider"},
+		{"WithHTTPClient nil", WithHTTPClient(nil), "WithHTTPClient"},
+		{"WithInstallationManager nil", WithInstallationManager(nil), "WithInstallationManager"},
+		{"WithContext nil", WithContext(nil), "WithContext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIntegration(tt.opt)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNewIntegrationAppliesValidOptions(t *testing.T) {
+	store := &BoltStateStore{}
+	gh, err := NewIntegration(
+		WithRepo("octocat", "hello-world"),
+		WithPullRequest(1),
+		WithApp(1, 2),
+		WithCheckRunName("simulate"),
+		WithStateStore(store),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "octocat", gh.GetOwner())
+	require.Equal(t, "hello-world", gh.GetRepo())
+	require.Equal(t, 1, gh.GetPrNum())
+	require.Same(t, store, gh.State)
+}
+
+// TestConfigFromScratchReturnsErrorOnBadID proves a non-numeric installation/integration/PR ID
+// returns an error instead of panicking - the footgun this request replaced NewIntegration's
+// positional-string predecessor for.
+func TestConfigFromScratchReturnsErrorOnBadID(t *testing.T) {
+	gh := &Integration{}
+	err := gh.ConfigFromScratch("us-east-1", "key-id", "octocat", "hello-world", "simulate",
+		"not-a-number", "2", "3")
+	require.Error(t, err)
+}
+
+func TestIntegrationContextDefaultsToBackground(t *testing.T) {
+	gh := &Integration{}
+	require.Equal(t, context.Background(), gh.context())
+
+	ctx := context.WithValue(context.Background(), struct{}{}, "x")
+	gh2, err := NewIntegration(WithRepo("o", "r"), WithContext(ctx))
+	require.NoError(t, err)
+	require.Equal(t, ctx, gh2.context())
+}
+
+func TestIntegrationTransportDefaultsToDefaultTransport(t *testing.T) {
+	gh := &Integration{}
+	require.Equal(t, http.DefaultTransport, gh.transport())
+
+	custom := &http.Transport{}
+	gh2, err := NewIntegration(WithRepo("o", "r"), WithHTTPClient(&http.Client{Transport: custom}))
+	require.NoError(t, err)
+	require.Equal(t, custom, gh2.transport())
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,27 +17,55 @@ import (
 const primaryKey = "IntegrationType"
 const tableName = "SimulationState"
 
+// stateKeyFor computes the StateStore row key for a given owner/repo/PR. It's a free function,
+// not just Integration.StateKey, because ConfigFromState needs to address the row before it
+// has a populated Integration to call StateKey on.
+func stateKeyFor(owner, repo string, prNum int) string {
+	return owner + "/" + repo + "::" + strconv.Itoa(prNum)
+}
+
 type Integration struct {
-	Client          *github.Client
-	PR              *github.PullRequest
-	ActiveCheckRun  *github.CheckRun
-	State           *runsimaws.DdbTable
-	IntegrationType *string
-	CheckRunName    *string
-	InstallationID  *string
-	IntegrationID   *string
-	RepoOwner       *string
-	RepoName        *string
-	PrNum           *string
-}
-
-// Retrieve simulation state data from DynamoDB
+	Client           *github.Client
+	PR               *github.PullRequest
+	ActiveCheckRun   *github.CheckRun
+	ActiveCheckRunID *int64
+	State            StateStore
+	IntegrationType  *string
+	CheckRunName     *string
+	InstallationID   int64
+	IntegrationID    int64
+	RepoOwner        *string
+	RepoName         *string
+	PrNum            int64
+
+	ctx           context.Context
+	secrets       SecretProvider
+	httpClient    *http.Client
+	installations *InstallationManager
+	progress      chan ProgressEvent
+	progressOnce  sync.Once
+
+	pendingAnnotations []SimAnnotation
+}
+
+// Retrieve simulation state data from DynamoDB, addressed by the owner/repo/PR the caller is
+// resuming - that's the only way to look up the right row before gh itself is populated from it.
 // Use the state data to configure the github api client and assign value to the integration fields
-func (gh *Integration) ConfigFromState(awsRegion, ghAccessTokenID string) (err error) {
-	gh.State = new(runsimaws.DdbTable)
-	gh.State.Config(awsRegion, primaryKey, tableName)
+func (gh *Integration) ConfigFromState(awsRegion, ghAccessTokenID, repoOwner, repoName, prNum string) (err error) {
+	ddb := new(runsimaws.DdbTable)
+	ddb.Config(awsRegion, primaryKey, tableName)
+	gh.State = ddb
 
-	if err = gh.State.GetState("GitHub", gh); err != nil {
+	ssm := new(runsimaws.Ssm)
+	ssm.Config(awsRegion)
+	gh.secrets = ssm
+
+	pr, err := strconv.Atoi(prNum)
+	if err != nil {
+		return
+	}
+
+	if err = gh.State.GetState(stateKeyFor(repoOwner, repoName, pr), gh); err != nil {
 		return
 	}
 
@@ -44,81 +73,100 @@ func (gh *Integration) ConfigFromState(awsRegion, ghAccessTokenID string) (err e
 		return
 	}
 
-	ssm := new(runsimaws.Ssm)
-	ssm.Config(awsRegion)
-	privateKey, err := ssm.GetParameter(ghAccessTokenID)
+	privateKey, err := gh.secrets.GetParameter(ghAccessTokenID)
 	if err != nil {
 		return
 	}
 
 	// authenticate the gh app
-	transport, err := ghapp.New(http.DefaultTransport, gh.GetAppIntID(), gh.GetAppInstID(), []byte(privateKey))
+	transport, err := ghapp.New(gh.transport(), gh.GetAppIntID(), gh.GetAppInstID(), []byte(privateKey))
 	if err != nil {
 		return
 	}
 
 	gh.Client = github.NewClient(&http.Client{Transport: transport})
 
-	gh.PR, _, err = gh.Client.PullRequests.Get(context.Background(), gh.GetOwner(), gh.GetRepo(), gh.GetPrNum())
+	gh.PR, _, err = gh.Client.PullRequests.Get(gh.context(), gh.GetOwner(), gh.GetRepo(), gh.GetPrNum())
 	return
 }
 
-// Config the github client and assign values to the integration fields
+// ConfigFromScratch builds the Integration via NewIntegration from positional arguments,
+// parsing the numeric IDs and returning an error on a bad value instead of panicking later.
 func (gh *Integration) ConfigFromScratch(awsRegion, privateKeyID, repoOwner, repoName, checkRunName,
 	installationID, integrationID, prNum string) (err error) {
-	gh.RepoOwner = &repoOwner
-	gh.RepoName = &repoName
-	gh.CheckRunName = &checkRunName
-	gh.InstallationID = &installationID
-	gh.IntegrationID = &integrationID
-	gh.PrNum = &prNum
-	gh.IntegrationType = aws.String("GitHub")
-	gh.State = new(runsimaws.DdbTable)
-	gh.State.Config(awsRegion, primaryKey, tableName)
-
-	if err = gh.State.PutState(gh); err != nil {
+	instID, err := strconv.ParseInt(installationID, 10, 64)
+	if err != nil {
+		return
+	}
+	intID, err := strconv.ParseInt(integrationID, 10, 64)
+	if err != nil {
+		return
+	}
+	pr, err := strconv.Atoi(prNum)
+	if err != nil {
 		return
 	}
 
+	ddb := new(runsimaws.DdbTable)
+	ddb.Config(awsRegion, primaryKey, tableName)
+
 	ssm := new(runsimaws.Ssm)
 	ssm.Config(awsRegion)
-	privateKey, err := ssm.GetParameter(privateKeyID)
+
+	built, err := NewIntegration(
+		WithRepo(repoOwner, repoName),
+		WithCheckRunName(checkRunName),
+		WithApp(intID, instID),
+		WithPullRequest(pr),
+		WithStateStore(ddb),
+		WithSecretProvider(ssm),
+	)
+	if err != nil {
+		return
+	}
+	*gh = *built
+
+	if err = gh.State.PutState(gh); err != nil {
+		return
+	}
+
+	privateKey, err := gh.secrets.GetParameter(privateKeyID)
 	if err != nil {
 		return
 	}
 	// authenticate the gh app
-	transport, err := ghapp.New(http.DefaultTransport, gh.GetAppIntID(), gh.GetAppInstID(), []byte(privateKey))
+	transport, err := ghapp.New(gh.transport(), gh.GetAppIntID(), gh.GetAppInstID(), []byte(privateKey))
 	if err != nil {
 		return
 	}
 
 	gh.Client = github.NewClient(&http.Client{Transport: transport})
 
-	gh.PR, _, err = gh.Client.PullRequests.Get(context.Background(), gh.GetOwner(), gh.GetRepo(), gh.GetPrNum())
+	gh.PR, _, err = gh.Client.PullRequests.Get(gh.context(), gh.GetOwner(), gh.GetRepo(), gh.GetPrNum())
 	return
 }
 
-func (gh *Integration) CreateNewCheckRun() (err error) {
+func (gh *Integration) CreateNewCheckRun(ctx context.Context) (err error) {
 	opt := github.CreateCheckRunOptions{
 		Name:       gh.GetCheckRunName(),
 		HeadBranch: gh.PR.Head.GetRef(),
 		HeadSHA:    gh.PR.Head.GetSHA(),
 	}
 
-	gh.ActiveCheckRun, _, err = gh.Client.Checks.CreateCheckRun(context.Background(), gh.GetOwner(), gh.GetRepo(), opt)
+	gh.ActiveCheckRun, _, err = gh.Client.Checks.CreateCheckRun(ctx, gh.GetOwner(), gh.GetRepo(), opt)
 	if err != nil {
 		return
 	}
 
 	gh.CheckRunName = gh.ActiveCheckRun.Name
 
-	return
+	return gh.stashActiveCheckRunID()
 }
 
 // Search for any active check runs associated with the pull request.
 // An active check run is defined as not having the "Conclusion" field set.
-func (gh *Integration) SetActiveCheckRun() (err error) {
-	listCheckRunResult, _, err := gh.Client.Checks.ListCheckRunsForRef(context.Background(),
+func (gh *Integration) SetActiveCheckRun(ctx context.Context) (err error) {
+	listCheckRunResult, _, err := gh.Client.Checks.ListCheckRunsForRef(ctx,
 		gh.GetOwner(), gh.GetRepo(), gh.PR.Head.GetRef(),
 		&github.ListCheckRunsOptions{
 			CheckName: aws.String(gh.GetCheckRunName()),
@@ -131,21 +179,31 @@ func (gh *Integration) SetActiveCheckRun() (err error) {
 		return errors.New("ErrorNoActiveCheckRunsFound")
 	}
 	gh.ActiveCheckRun = listCheckRunResult.CheckRuns[0]
-	return
+	return gh.stashActiveCheckRunID()
 }
 
 // Retrieve a new copy of the active check run. New copy contains any fields which have been updated since
 // the active check run has been set
-func (gh *Integration) UpdateActiveCheckRun() (err error) {
-	gh.ActiveCheckRun, _, err = gh.Client.Checks.GetCheckRun(context.Background(),
+func (gh *Integration) UpdateActiveCheckRun(ctx context.Context) (err error) {
+	gh.ActiveCheckRun, _, err = gh.Client.Checks.GetCheckRun(ctx,
 		gh.GetOwner(), gh.GetRepo(), gh.ActiveCheckRun.GetID())
 	if err != nil {
 		return
 	}
-	return
+	return gh.stashActiveCheckRunID()
 }
 
-func (gh *Integration) ConcludeCheckRun(summary, conclusion *string) (err error) {
+// ConcludeCheckRun is idempotent across retries: if the caller (or a crashed worker resuming
+// via ConfigFromState) has no in-memory ActiveCheckRun, it's recovered from the last-known
+// ActiveCheckRunID stashed in the StateStore so the PATCH lands on the same check run instead
+// of orphaning it.
+func (gh *Integration) ConcludeCheckRun(ctx context.Context, summary, conclusion *string) (err error) {
+	if gh.ActiveCheckRun == nil {
+		if err = gh.recoverActiveCheckRun(ctx); err != nil {
+			return
+		}
+	}
+
 	opt := github.UpdateCheckRunOptions{
 		Name:        gh.ActiveCheckRun.GetName(),
 		Status:      aws.String("completed"),
@@ -158,13 +216,13 @@ func (gh *Integration) ConcludeCheckRun(summary, conclusion *string) (err error)
 		},
 	}
 
-	gh.ActiveCheckRun, _, err = gh.Client.Checks.UpdateCheckRun(context.Background(), gh.GetOwner(), gh.GetRepo(),
+	gh.ActiveCheckRun, _, err = gh.Client.Checks.UpdateCheckRun(ctx, gh.GetOwner(), gh.GetRepo(),
 		gh.ActiveCheckRun.GetID(), opt)
 
 	return
 }
 
-func (gh *Integration) UpdateCheckRunStatus(status, summary *string) (err error) {
+func (gh *Integration) UpdateCheckRunStatus(ctx context.Context, status, summary *string) (err error) {
 	opt := github.UpdateCheckRunOptions{
 		Name:       gh.ActiveCheckRun.GetName(),
 		HeadBranch: gh.PR.Head.Ref,
@@ -178,14 +236,48 @@ func (gh *Integration) UpdateCheckRunStatus(status, summary *string) (err error)
 		}
 	}
 
-	gh.ActiveCheckRun, _, err = gh.Client.Checks.UpdateCheckRun(context.Background(), gh.GetOwner(), gh.GetRepo(),
+	gh.ActiveCheckRun, _, err = gh.Client.Checks.UpdateCheckRun(ctx, gh.GetOwner(), gh.GetRepo(),
 		gh.ActiveCheckRun.GetID(), opt)
 
 	return
 }
 
-func (gh *Integration) DeleteState() (err error) {
-	return gh.State.DeleteState("GitHub")
+func (gh *Integration) DeleteState(ctx context.Context) (err error) {
+	return gh.State.DeleteState(gh.StateKey())
+}
+
+// stashActiveCheckRunID records gh.ActiveCheckRun's ID as gh.ActiveCheckRunID and persists it
+// to the StateStore, so ConcludeCheckRun can recover it after a crash/restart by re-fetching
+// the check run with that ID.
+func (gh *Integration) stashActiveCheckRunID() error {
+	if gh.ActiveCheckRun == nil || gh.State == nil {
+		return nil
+	}
+	gh.ActiveCheckRunID = gh.ActiveCheckRun.ID
+	return gh.State.PutState(gh)
+}
+
+// recoverActiveCheckRun re-fetches ActiveCheckRun by its stashed ID when it isn't already
+// held in memory, e.g. for a worker that resumed from ConfigFromState after a crash.
+func (gh *Integration) recoverActiveCheckRun(ctx context.Context) error {
+	if gh.ActiveCheckRunID == nil {
+		return errors.New("ErrorNoActiveCheckRunID")
+	}
+
+	checkRun, _, err := gh.Client.Checks.GetCheckRun(ctx, gh.GetOwner(), gh.GetRepo(), *gh.ActiveCheckRunID)
+	if err != nil {
+		return err
+	}
+	gh.ActiveCheckRun = checkRun
+	return nil
+}
+
+// StateKey identifies this integration's state row for StateStore implementations, such as
+// BoltStateStore, that key rows by owner/repo/PR rather than a fixed DynamoDB primary key. It
+// must compute the same key ConfigFromState's stateKeyFor call does, so a row PutState writes
+// is the same row a later ConfigFromState/DeleteState for that owner/repo/PR addresses.
+func (gh *Integration) StateKey() string {
+	return stateKeyFor(gh.GetOwner(), gh.GetRepo(), gh.GetPrNum())
 }
 
 func (gh *Integration) GetOwner() string {
@@ -200,38 +292,26 @@ func (gh *Integration) GetCheckRunName() string {
 	return *gh.CheckRunName
 }
 
-func (gh *Integration) GetPrNum() (num int) {
-	num, err := strconv.Atoi(*gh.PrNum)
-	if err != nil {
-		panic(err)
-	}
-	return
+func (gh *Integration) GetPrNum() int {
+	return int(gh.PrNum)
 }
 
-func (gh *Integration) GetAppInstID() (id int) {
-	id, err := strconv.Atoi(*gh.InstallationID)
-	if err != nil {
-		panic(err)
-	}
-	return
+func (gh *Integration) GetAppInstID() int64 {
+	return gh.InstallationID
 }
 
-func (gh *Integration) GetAppIntID() (id int) {
-	id, err := strconv.Atoi(*gh.IntegrationID)
-	if err != nil {
-		panic(err)
-	}
-	return
+func (gh *Integration) GetAppIntID() int64 {
+	return gh.IntegrationID
 }
 
 func (gh *Integration) ValidateState() (err error) {
-	if gh.IntegrationID == nil {
+	if gh.IntegrationID <= 0 {
 		return errors.New("ErrorMissingAttribute: IntegrationID")
 	}
-	if gh.InstallationID == nil {
+	if gh.InstallationID <= 0 {
 		return errors.New("ErrorMissingAttribute: InstallationID ")
 	}
-	if gh.PrNum == nil {
+	if gh.PrNum <= 0 {
 		return errors.New("ErrorMissingAttribute: PrNum")
 	}
 	if gh.RepoName == nil {
@@ -0,0 +1,9 @@
+package runsimgh
+
+// StateStore persists an Integration's simulation state (check run metadata, active PR
+// locks, and the like) so a worker can recover it across restarts via ConfigFromState.
+type StateStore interface {
+	GetState(key string, out interface{}) error
+	PutState(in interface{}) error
+	DeleteState(key string) error
+}
@@ -0,0 +1,66 @@
+package runsimgh
+
+import "context"
+
+// progressBuffer is how many pushed-but-not-yet-streamed ProgressEvents PushProgress will
+// buffer before it blocks the simulator.
+const progressBuffer = 16
+
+// ProgressEvent is a single progress update a simulation reports while running. Stream applies
+// it to the active check run via UpdateCheckRunStatus and forwards it to the caller.
+type ProgressEvent struct {
+	Status  string
+	Summary string
+}
+
+// progressChan lazily initializes gh.progress so Integrations built without Stream in mind
+// (e.g. via ConfigFromState) don't need to know about it up front. The init is guarded by
+// gh.progressOnce since PushProgress and Stream are meant to run concurrently from different
+// goroutines and could otherwise race to create two different channels.
+func (gh *Integration) progressChan() chan ProgressEvent {
+	gh.progressOnce.Do(func() {
+		gh.progress = make(chan ProgressEvent, progressBuffer)
+	})
+	return gh.progress
+}
+
+// PushProgress enqueues a progress update for Stream to apply to the active check run. It's
+// how a simulator reports progress without depending on Stream's internals.
+func (gh *Integration) PushProgress(event ProgressEvent) {
+	gh.progressChan() <- event
+}
+
+// Stream applies ProgressEvents pushed via PushProgress to the active check run with
+// UpdateCheckRunStatus, and forwards each applied event on the returned channel for the
+// caller to fan out to logs/metrics. Both channels close once ctx is done.
+func (gh *Integration) Stream(ctx context.Context) (<-chan ProgressEvent, <-chan error) {
+	out := make(chan ProgressEvent)
+	errs := make(chan error, 1)
+	events := gh.progressChan()
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				status, summary := event.Status, event.Summary
+				if err := gh.UpdateCheckRunStatus(ctx, &status, &summary); err != nil {
+					errs <- err
+					return
+				}
+
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, errs
+}
@@ -0,0 +1,78 @@
+package runsimgh
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+	"github.com/stretchr/testify/require"
+)
+
+// staticTransport answers every request with the same canned check-run JSON, so Stream's
+// UpdateCheckRunStatus calls succeed without hitting the network.
+type staticTransport struct {
+	body string
+}
+
+func (t *staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+// TestStreamPushProgressConcurrentInit starts Stream and calls PushProgress from another
+// goroutine at the same time, before gh.progress is known to exist. Run with -race: without
+// progressOnce guarding the lazy init, the two goroutines can each create their own channel,
+// so the pushed event is enqueued on a channel nobody drains.
+func TestStreamPushProgressConcurrentInit(t *testing.T) {
+	owner, repo, name := "octocat", "hello-world", "simulate"
+	client := github.NewClient(&http.Client{
+		Transport: &staticTransport{body: `{"id":1,"name":"simulate","status":"in_progress"}`},
+	})
+
+	gh := &Integration{
+		Client:         client,
+		RepoOwner:      &owner,
+		RepoName:       &repo,
+		CheckRunName:   &name,
+		ActiveCheckRun: &github.CheckRun{ID: github.Int64(1), Name: &name},
+		PR: &github.PullRequest{Head: &github.PullRequestBranch{
+			Ref: github.String("main"),
+			SHA: github.String("deadbeef"),
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var out <-chan ProgressEvent
+	var errs <-chan error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		out, errs = gh.Stream(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		gh.PushProgress(ProgressEvent{Status: "in_progress", Summary: "working"})
+	}()
+	wg.Wait()
+
+	select {
+	case event := <-out:
+		require.Equal(t, "working", event.Summary)
+	case err := <-errs:
+		t.Fatalf("stream reported error: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the pushed event to be streamed back")
+	}
+}